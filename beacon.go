@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// maxBeaconBodyBytes bounds how much of a /beacon request body is read,
+// since the payload is a handful of JSON fields from an untrusted browser.
+const maxBeaconBodyBytes = 4096
+
+// beaconPayload is the JSON body sent by the client-side beacon script: the
+// page path, and the start/end of the visit as Unix timestamps in seconds.
+type beaconPayload struct {
+	Path      string  `json:"path"`
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
+}
+
+// originStore holds the current beacon origin allowlist, swappable on
+// config reload the same way the Scheduler's target set and the
+// moduleStore's probe modules are.
+type originStore struct {
+	mu      sync.RWMutex
+	origins []string
+}
+
+func newOriginStore(origins []string) *originStore {
+	return &originStore{origins: origins}
+}
+
+func (s *originStore) Set(origins []string) {
+	s.mu.Lock()
+	s.origins = origins
+	s.mu.Unlock()
+}
+
+func (s *originStore) Allowed(origin string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, allowed := range s.origins {
+		if origin == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// pathStore holds the set of paths the /beacon endpoint will accept,
+// swappable on config reload the same way originStore is. Bounding
+// accepted paths to this set keeps website_client_read_seconds from
+// accumulating one series per arbitrary client-submitted path.
+type pathStore struct {
+	mu    sync.RWMutex
+	paths map[string]bool
+}
+
+func newPathStore(paths map[string]bool) *pathStore {
+	return &pathStore{paths: paths}
+}
+
+func (s *pathStore) Set(paths map[string]bool) {
+	s.mu.Lock()
+	s.paths = paths
+	s.mu.Unlock()
+}
+
+func (s *pathStore) Allowed(path string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.paths[path]
+}
+
+// targetPaths returns the set of URL paths configured across targets, the
+// allowlist a beacon's path must belong to.
+func targetPaths(targets []target) map[string]bool {
+	paths := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		if u, err := url.Parse(t.url); err == nil && u.Path != "" {
+			paths[u.Path] = true
+		}
+	}
+	return paths
+}
+
+// beaconHandler implements a RUM ingestion endpoint for client-side beacons:
+// a page reports how long it was actually read for, which lands in
+// website_client_read_seconds alongside the server-side metrics for the same
+// site. A JSON POST from another origin isn't a CORS-simple request, so
+// browsers preflight it with OPTIONS; that's answered here with the
+// allowlist check plus Access-Control-Allow-Methods/Headers before the
+// actual POST ever lands. Only origins in originStore's allowlist may post,
+// a DNT: 1 request is acknowledged without being recorded, and only paths in
+// pathStore (the exporter's own configured targets) are accepted as label
+// values, so an arbitrary client can't mint unbounded path series.
+func beaconHandler(origins *originStore, paths *pathStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			origin := r.Header.Get("Origin")
+			if !origins.Allowed(origin) {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "POST")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		origin := r.Header.Get("Origin")
+		if !origins.Allowed(origin) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+
+		if r.Header.Get("DNT") == "1" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var payload beaconPayload
+		body := http.MaxBytesReader(w, r.Body, maxBeaconBodyBytes)
+		if err := json.NewDecoder(body).Decode(&payload); err != nil {
+			http.Error(w, "invalid beacon payload", http.StatusBadRequest)
+			return
+		}
+
+		readSeconds := payload.EndTime - payload.StartTime
+		if readSeconds < 0 {
+			http.Error(w, "invalid beacon payload", http.StatusBadRequest)
+			return
+		}
+		if !paths.Allowed(payload.Path) {
+			http.Error(w, "path not allowed", http.StatusBadRequest)
+			return
+		}
+
+		clientReadSeconds.WithLabelValues(payload.Path).Observe(readSeconds)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
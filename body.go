@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/andybalholm/brotli"
+)
+
+// defaultMaxBodyBytes caps how much of a response body is read when a
+// target doesn't configure max_body_bytes, so a single misbehaving target
+// can't OOM the exporter.
+const defaultMaxBodyBytes = 10 * 1024 * 1024 // 10 MiB
+
+// countingReader tracks how many bytes have passed through it, used to
+// measure the compressed size on the wire independent of decoding.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// decodeBody wraps r with the decompressor named by contentEncoding. An
+// unrecognized encoding is passed through unchanged, so the body ends up
+// being whatever bytes the server actually sent.
+func decodeBody(r io.Reader, contentEncoding string) (io.Reader, error) {
+	switch contentEncoding {
+	case "", "identity":
+		return r, nil
+	case "gzip":
+		return gzip.NewReader(r)
+	case "deflate":
+		return flate.NewReader(r), nil
+	case "br":
+		return brotli.NewReader(r), nil
+	default:
+		return r, nil
+	}
+}
+
+// readBody streams resp.Body through up to maxBodyBytes of decoded content
+// into a bounded buffer, rather than buffering the whole response the way
+// io.ReadAll does, then hashes the (possibly truncated) result. It reports
+// the wire size (bytes read off the network before decoding), the decoded
+// size, the decoded body's SHA-256, and whether the cap was hit.
+func readBody(resp *http.Response, maxBodyBytes int64) (body []byte, wireBytes int64, hash string, truncated bool, err error) {
+	wire := &countingReader{r: resp.Body}
+
+	decoded, err := decodeBody(wire, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, 0, "", false, fmt.Errorf("failed to decode response body: %w", err)
+	}
+	if closer, ok := decoded.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	var buf bytes.Buffer
+	limited := io.LimitReader(decoded, maxBodyBytes+1)
+	if _, err := io.Copy(&buf, limited); err != nil {
+		return nil, wire.n, "", false, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	body = buf.Bytes()
+	if int64(len(body)) > maxBodyBytes {
+		body = body[:maxBodyBytes]
+		truncated = true
+	}
+
+	sum := sha256.Sum256(body)
+	return body, wire.n, hex.EncodeToString(sum[:]), truncated, nil
+}
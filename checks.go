@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+)
+
+// target is a single monitored URL plus the content assertions, schedule,
+// and request shape configured for it.
+type target struct {
+	url      string
+	checks   []checkSpec
+	schedule schedule
+	request  requestConfig
+}
+
+// scrapeResult is the outcome of a single fetch, as seen by content checks.
+type scrapeResult struct {
+	status int
+	body   []byte
+	hash   string // hex SHA-256 of body, precomputed by readBody
+
+	// certNotAfter is the leaf TLS certificate's expiry, zero if the fetch
+	// wasn't over TLS.
+	certNotAfter time.Time
+}
+
+// checkSpec is one parsed assertion, e.g. "contains=Welcome" or "status=200".
+type checkSpec struct {
+	name string
+	eval func(r scrapeResult) bool
+}
+
+// parseCheckSpec parses a single "key=value" check directive from a
+// target's or module's `checks` list in the config file.
+func parseCheckSpec(directive string) (checkSpec, error) {
+	key, value, ok := strings.Cut(directive, "=")
+	if !ok {
+		return checkSpec{}, fmt.Errorf("invalid check directive %q: expected key=value", directive)
+	}
+
+	switch key {
+	case "contains":
+		return checkSpec{name: directive, eval: func(r scrapeResult) bool {
+			return bytes.Contains(r.body, []byte(value))
+		}}, nil
+
+	case "not_contains":
+		return checkSpec{name: directive, eval: func(r scrapeResult) bool {
+			return !bytes.Contains(r.body, []byte(value))
+		}}, nil
+
+	case "regex":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return checkSpec{}, fmt.Errorf("invalid regex check %q: %w", directive, err)
+		}
+		return checkSpec{name: directive, eval: func(r scrapeResult) bool {
+			return re.Match(r.body)
+		}}, nil
+
+	case "min_size":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return checkSpec{}, fmt.Errorf("invalid min_size check %q: %w", directive, err)
+		}
+		return checkSpec{name: directive, eval: func(r scrapeResult) bool {
+			return len(r.body) >= n
+		}}, nil
+
+	case "max_size":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return checkSpec{}, fmt.Errorf("invalid max_size check %q: %w", directive, err)
+		}
+		return checkSpec{name: directive, eval: func(r scrapeResult) bool {
+			return len(r.body) <= n
+		}}, nil
+
+	case "sha256":
+		want := strings.ToLower(value)
+		return checkSpec{name: directive, eval: func(r scrapeResult) bool {
+			return r.hash == want
+		}}, nil
+
+	case "status":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return checkSpec{}, fmt.Errorf("invalid status check %q: %w", directive, err)
+		}
+		return checkSpec{name: directive, eval: func(r scrapeResult) bool {
+			return r.status == n
+		}}, nil
+
+	case "xpath":
+		if _, err := xpath.Compile(value); err != nil {
+			return checkSpec{}, fmt.Errorf("invalid xpath check %q: %w", directive, err)
+		}
+		return checkSpec{name: directive, eval: func(r scrapeResult) bool {
+			doc, err := htmlquery.Parse(bytes.NewReader(r.body))
+			if err != nil {
+				return false
+			}
+			node, err := htmlquery.Query(doc, value)
+			return err == nil && node != nil
+		}}, nil
+
+	case "tls_expiry":
+		min, err := time.ParseDuration(value)
+		if err != nil {
+			return checkSpec{}, fmt.Errorf("invalid tls_expiry check %q: %w", directive, err)
+		}
+		return checkSpec{name: directive, eval: func(r scrapeResult) bool {
+			return !r.certNotAfter.IsZero() && time.Until(r.certNotAfter) >= min
+		}}, nil
+
+	default:
+		return checkSpec{}, fmt.Errorf("unknown check directive %q", directive)
+	}
+}
+
+// runChecks evaluates every check attached to a target against the result of
+// its last scrape and records website_check_success for each into m.
+func runChecks(m *metricsSet, url string, checks []checkSpec, r scrapeResult) {
+	for _, c := range checks {
+		success := 0.0
+		if c.eval(r) {
+			success = 1.0
+		}
+		m.checkSuccess.WithLabelValues(url, c.name).Set(success)
+	}
+}
+
+// recordContentHash records url's current content hash into m, retiring the
+// previous hash's series first. The last-seen hash is tracked on m itself
+// (not package-wide), so the ephemeral per-request metricsSet that
+// probeHandler builds for each /probe call can't have its one-off hash
+// evict the hash a concurrent scheduled scrape of the same URL just
+// recorded into defaultMetrics, or vice versa.
+func recordContentHash(m *metricsSet, url, hash string) {
+	m.lastHashMu.Lock()
+	prev, ok := m.lastHash[url]
+	m.lastHash[url] = hash
+	m.lastHashMu.Unlock()
+
+	if ok && prev != hash {
+		m.contentHash.DeleteLabelValues(url, prev)
+	}
+	m.contentHash.WithLabelValues(url, hash).Set(1)
+}
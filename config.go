@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the root of the YAML configuration file.
+type Config struct {
+	Global  GlobalConfig            `yaml:"global"`
+	Targets []TargetConfig          `yaml:"targets"`
+	Modules map[string]ModuleConfig `yaml:"modules"`
+	Beacon  BeaconConfig            `yaml:"beacon"`
+}
+
+// BeaconConfig configures the /beacon RUM ingestion endpoint. With no
+// origins allowlisted, every request is rejected and the endpoint is
+// effectively disabled.
+type BeaconConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+// GlobalConfig holds defaults applied to every target unless it overrides
+// them.
+type GlobalConfig struct {
+	Interval     time.Duration `yaml:"interval"`
+	Timeout      time.Duration `yaml:"timeout"`
+	Jitter       time.Duration `yaml:"jitter"`
+	MaxRetries   int           `yaml:"max_retries"`
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+	MaxBodyBytes int64         `yaml:"max_body_bytes"`
+
+	// Buckets sets the bucket boundaries for every duration histogram this
+	// exporter exposes (fetch, TTFB, scrape, and /beacon's client read time).
+	// It's read once at startup; a config reload can't change it, since
+	// Prometheus histograms fix their buckets at creation.
+	Buckets []float64 `yaml:"buckets"`
+}
+
+// BasicAuth holds HTTP basic-auth credentials for a target.
+type BasicAuth struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// TLSConfig holds per-target TLS client options.
+type TLSConfig struct {
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	CAFile             string `yaml:"ca_file"`
+}
+
+// TargetConfig is one monitored target as written in the config file.
+type TargetConfig struct {
+	URL             string            `yaml:"url"`
+	Method          string            `yaml:"method"`
+	Body            string            `yaml:"body"`
+	Headers         map[string]string `yaml:"headers"`
+	BasicAuth       *BasicAuth        `yaml:"basic_auth"`
+	FollowRedirects *bool             `yaml:"follow_redirects"`
+	TLS             TLSConfig         `yaml:"tls"`
+	Checks          []string          `yaml:"checks"`
+	MaxBodyBytes    int64             `yaml:"max_body_bytes"`
+
+	Interval     time.Duration `yaml:"interval"`
+	Timeout      time.Duration `yaml:"timeout"`
+	Jitter       time.Duration `yaml:"jitter"`
+	MaxRetries   *int          `yaml:"max_retries"`
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+	Cron         string        `yaml:"cron"`
+}
+
+// ModuleConfig is a reusable request template for the /probe endpoint,
+// selected by the "module" query parameter (e.g. http_2xx, http_post_json).
+// Unlike TargetConfig it has no URL or schedule: the URL comes from the
+// probe request, and probes always run once, on demand.
+type ModuleConfig struct {
+	Method          string            `yaml:"method"`
+	Body            string            `yaml:"body"`
+	Headers         map[string]string `yaml:"headers"`
+	BasicAuth       *BasicAuth        `yaml:"basic_auth"`
+	FollowRedirects *bool             `yaml:"follow_redirects"`
+	TLS             TLSConfig         `yaml:"tls"`
+	Checks          []string          `yaml:"checks"`
+	Timeout         time.Duration     `yaml:"timeout"`
+	MaxBodyBytes    int64             `yaml:"max_body_bytes"`
+}
+
+// WatchConfig listens for SIGHUP and reloads the config file at path on each
+// one, invoking apply with the freshly parsed config. A reload that fails to
+// parse is logged and discarded, leaving the previous config (and therefore
+// the running target set) in place, the same way Prometheus's own web
+// package handles SIGHUP-triggered reloads.
+func WatchConfig(ctx context.Context, path string, apply func(*Config)) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				log.Printf("Failed to reload config file %s, keeping previous config: %v\n", path, err)
+				continue
+			}
+			log.Printf("Reloaded config file %s\n", path)
+			apply(cfg)
+		}
+	}
+}
+
+// LoadConfig reads and parses the YAML config file at path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if len(cfg.Targets) == 0 {
+		return nil, fmt.Errorf("config file %s defines no targets", path)
+	}
+	return &cfg, nil
+}
+
+// buildTargets merges the global defaults into each target config and
+// returns the runtime targets the Scheduler consumes.
+func (c *Config) buildTargets() ([]target, error) {
+	targets := make([]target, 0, len(c.Targets))
+	for _, tc := range c.Targets {
+		req, err := requestConfigFrom(tc)
+		if err != nil {
+			return nil, fmt.Errorf("target %s: %w", tc.URL, err)
+		}
+		if req.MaxBodyBytes <= 0 {
+			req.MaxBodyBytes = c.Global.MaxBodyBytes
+		}
+
+		t := target{
+			url:      tc.URL,
+			schedule: defaultSchedule(),
+			request:  req,
+		}
+
+		if c.Global.Interval > 0 {
+			t.schedule.Interval = c.Global.Interval
+		}
+		if c.Global.Timeout > 0 {
+			t.schedule.Timeout = c.Global.Timeout
+		}
+		if c.Global.Jitter > 0 {
+			t.schedule.Jitter = c.Global.Jitter
+		}
+		if c.Global.RetryBackoff > 0 {
+			t.schedule.Backoff = c.Global.RetryBackoff
+		}
+		t.schedule.MaxRetries = c.Global.MaxRetries
+
+		if tc.Interval > 0 {
+			t.schedule.Interval = tc.Interval
+		}
+		if tc.Timeout > 0 {
+			t.schedule.Timeout = tc.Timeout
+		}
+		if tc.Jitter > 0 {
+			t.schedule.Jitter = tc.Jitter
+		}
+		if tc.RetryBackoff > 0 {
+			t.schedule.Backoff = tc.RetryBackoff
+		}
+		if tc.MaxRetries != nil {
+			t.schedule.MaxRetries = *tc.MaxRetries
+		}
+		if tc.Cron != "" {
+			t.schedule.Cron = tc.Cron
+		}
+
+		for _, directive := range tc.Checks {
+			spec, err := parseCheckSpec(directive)
+			if err != nil {
+				return nil, fmt.Errorf("target %s: %w", tc.URL, err)
+			}
+			t.checks = append(t.checks, spec)
+		}
+
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// buildModules converts each configured module into the probeModule shape
+// the /probe endpoint consumes.
+func (c *Config) buildModules() (map[string]probeModule, error) {
+	modules := make(map[string]probeModule, len(c.Modules))
+	for name, mc := range c.Modules {
+		req, err := requestConfigFrom(TargetConfig{
+			Method:          mc.Method,
+			Body:            mc.Body,
+			Headers:         mc.Headers,
+			BasicAuth:       mc.BasicAuth,
+			FollowRedirects: mc.FollowRedirects,
+			TLS:             mc.TLS,
+			MaxBodyBytes:    mc.MaxBodyBytes,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("module %s: %w", name, err)
+		}
+		if req.MaxBodyBytes <= 0 {
+			req.MaxBodyBytes = c.Global.MaxBodyBytes
+		}
+
+		var checks []checkSpec
+		for _, directive := range mc.Checks {
+			spec, err := parseCheckSpec(directive)
+			if err != nil {
+				return nil, fmt.Errorf("module %s: %w", name, err)
+			}
+			checks = append(checks, spec)
+		}
+
+		timeout := mc.Timeout
+		if timeout <= 0 {
+			timeout = 10 * time.Second
+		}
+
+		modules[name] = probeModule{request: req, checks: checks, timeout: timeout}
+	}
+	return modules, nil
+}
+
+// requestConfig holds the shape of the HTTP request issued for a target, as
+// configured in the YAML file.
+type requestConfig struct {
+	Method          string
+	Headers         map[string]string
+	Body            string
+	BasicAuth       *BasicAuth
+	FollowRedirects bool
+	Transport       *http.Transport
+	MaxBodyBytes    int64
+}
+
+func requestConfigFrom(tc TargetConfig) (requestConfig, error) {
+	method := tc.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	followRedirects := true
+	if tc.FollowRedirects != nil {
+		followRedirects = *tc.FollowRedirects
+	}
+
+	transport, err := buildTransport(tc.TLS)
+	if err != nil {
+		return requestConfig{}, err
+	}
+
+	return requestConfig{
+		Method:          method,
+		Headers:         tc.Headers,
+		Body:            tc.Body,
+		BasicAuth:       tc.BasicAuth,
+		FollowRedirects: followRedirects,
+		Transport:       transport,
+		MaxBodyBytes:    tc.MaxBodyBytes,
+	}, nil
+}
+
+// buildTransport clones the default transport and applies per-target TLS
+// options, so proxy/dialer defaults are preserved unless a target overrides
+// them.
+func buildTransport(cfg TLSConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_file %s: %w", cfg.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in ca_file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
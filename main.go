@@ -1,114 +1,244 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
-	"os"
+	"net/http/httptrace"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/alecthomas/kingpin/v2"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var (
-	pageSizeGauge = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "website_page_size_bytes",
-			Help: "Size of the website page in bytes",
-		},
-		[]string{"url"},
-	)
+	configFile = kingpin.Flag("config.file", "Path to the YAML configuration file.").
+			Default("websize-exporter.yml").String()
+	listenAddress = kingpin.Flag("web.listen-address", "Address to listen on for the web interface.").
+			Default(":9222").String()
+	logLevel = kingpin.Flag("log.level", "Only log messages at or above this severity.").
+			Default("info").Enum("debug", "info")
+
+	debugLogging bool
 )
 
-func init() {
-	prometheus.MustRegister(pageSizeGauge)
+const maxConcurrentScrapes = 10
+
+// debugf logs only when --log.level=debug, for the high-volume per-scrape
+// messages that are noise at the default level.
+func debugf(format string, args ...interface{}) {
+	if debugLogging {
+		log.Printf(format, args...)
+	}
 }
 
-func readSites(filename string) ([]string, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open file %s: %w", filename, err)
+// classifyError maps a fetch error to a coarse reason label for
+// website_scrape_errors_total.
+func classifyError(err error) string {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return "dns"
+	}
+
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return "tls"
 	}
-	defer file.Close()
 
-	return parseSites(file), nil
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+
+	return "other"
 }
 
-func parseSites(r io.Reader) []string {
-	var sites []string
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" && !strings.HasPrefix(line, "#") {
-			sites = append(sites, line)
-		}
+// normalizeContentType strips parameters (e.g. "; charset=utf-8") and
+// lower-cases the MIME type so the content_type label doesn't fragment
+// into one series per charset variant.
+func normalizeContentType(raw string) string {
+	if raw == "" {
+		return "unknown"
 	}
-	return sites
+	if i := strings.Index(raw, ";"); i >= 0 {
+		raw = raw[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(raw))
+}
+
+func recordFailure(m *metricsSet, url, reason string) {
+	m.pageSize.WithLabelValues(url, "unknown").Set(0)
+	m.up.WithLabelValues(url).Set(0)
+	m.scrapeErrs.WithLabelValues(url, reason).Inc()
 }
 
-func checkPageSize(url string, client *http.Client) {
-	resp, err := client.Get(url)
+// checkPageSize fetches t once and records all per-scrape metrics into m. It
+// reports whether the scrape should be considered successful, so callers
+// like runWithRetries know whether to retry.
+func checkPageSize(t target, base *http.Client, m *metricsSet) bool {
+	url := t.url
+	start := time.Now()
+	m.lastScrape.WithLabelValues(url).Set(float64(start.Unix()))
+
+	var redirects int
+	client := &http.Client{
+		Transport: base.Transport,
+		Timeout:   base.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !t.request.FollowRedirects {
+				return http.ErrUseLastResponse
+			}
+			redirects = len(via)
+			return nil
+		},
+	}
+
+	var ttfb time.Duration
+	trace := &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			ttfb = time.Since(start)
+		},
+	}
+	ctx := httptrace.WithClientTrace(context.Background(), trace)
+
+	var bodyReader io.Reader
+	if t.request.Body != "" {
+		bodyReader = strings.NewReader(t.request.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, t.request.Method, url, bodyReader)
+	if err != nil {
+		log.Printf("Error building request for URL %s: %v\n", url, err)
+		recordFailure(m, url, "other")
+		return false
+	}
+	// Set before applying configured headers so a target can still override
+	// it; otherwise Go's transport would transparently gzip-decode for us
+	// and readBody's own decompression would never see a Content-Encoding.
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	for key, value := range t.request.Headers {
+		req.Header.Set(key, value)
+	}
+	if t.request.BasicAuth != nil {
+		req.SetBasicAuth(t.request.BasicAuth.Username, t.request.BasicAuth.Password)
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
 		log.Printf("Error fetching URL %s: %v\n", url, err)
-		pageSizeGauge.WithLabelValues(url).Set(0)
-		return
+		recordFailure(m, url, classifyError(err))
+		return false
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	m.ttfbDur.WithLabelValues(url).Observe(ttfb.Seconds())
+	m.status.WithLabelValues(url).Set(float64(resp.StatusCode))
+	m.redirects.WithLabelValues(url).Set(float64(redirects))
+
+	nonSuccess := resp.StatusCode < 200 || resp.StatusCode >= 300
+	if nonSuccess {
+		log.Printf("Non-2xx status from URL %s: %d\n", url, resp.StatusCode)
+		m.scrapeErrs.WithLabelValues(url, "non_2xx").Inc()
+	}
+
+	maxBodyBytes := t.request.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultMaxBodyBytes
+	}
+	body, wireBytes, hash, truncated, err := readBody(resp, maxBodyBytes)
 	if err != nil {
 		log.Printf("Error reading response body from URL %s: %v\n", url, err)
-		pageSizeGauge.WithLabelValues(url).Set(0)
-		return
+		recordFailure(m, url, "other")
+		return false
+	}
+	if truncated {
+		log.Printf("Response body from URL %s exceeded %d bytes, truncating\n", url, maxBodyBytes)
+		m.bodyTruncated.WithLabelValues(url).Inc()
 	}
 
 	pageSize := len(body)
-	log.Printf("Fetched URL %s, size: %d bytes\n", url, pageSize)
-	pageSizeGauge.WithLabelValues(url).Set(float64(pageSize))
-}
+	contentType := normalizeContentType(resp.Header.Get("Content-Type"))
+	debugf("Fetched URL %s, size: %d bytes\n", url, pageSize)
+
+	m.pageSize.WithLabelValues(url, contentType).Set(float64(pageSize))
+	m.wireBytes.WithLabelValues(url).Set(float64(wireBytes))
+	m.decodedBytes.WithLabelValues(url).Set(float64(pageSize))
+	m.fetchDur.WithLabelValues(url).Observe(time.Since(start).Seconds())
+	recordContentHash(m, url, hash)
+
+	var certNotAfter time.Time
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		certNotAfter = resp.TLS.PeerCertificates[0].NotAfter
+	}
+	runChecks(m, url, t.checks, scrapeResult{status: resp.StatusCode, body: body, hash: hash, certNotAfter: certNotAfter})
 
-func monitorPages(urls []string, interval time.Duration) {
-	client := &http.Client{Timeout: 10 * time.Second}
-	for {
-		var wg sync.WaitGroup
-		for _, url := range urls {
-			wg.Add(1)
-			go func(url string) {
-				defer wg.Done()
-				checkPageSize(url, client)
-			}(url)
-		}
-		wg.Wait()
-		time.Sleep(interval)
+	if nonSuccess {
+		m.up.WithLabelValues(url).Set(0)
+		return false
 	}
+	m.up.WithLabelValues(url).Set(1)
+	return true
 }
 
 func main() {
-	const sitesFile = "sites.txt"
-	urls, err := readSites(sitesFile)
+	kingpin.Parse()
+	debugLogging = *logLevel == "debug"
+
+	cfg, err := LoadConfig(*configFile)
 	if err != nil {
-		log.Fatalf("Failed to load sites: %v\n", err)
+		log.Fatalf("Failed to load config: %v\n", err)
 	}
-	if len(urls) == 0 {
-		log.Fatalf("No URLs found in %s\n", sitesFile)
+
+	targets, err := cfg.buildTargets()
+	if err != nil {
+		log.Fatalf("Failed to build targets from config: %v\n", err)
+	}
+	modules, err := cfg.buildModules()
+	if err != nil {
+		log.Fatalf("Failed to build probe modules from config: %v\n", err)
 	}
 
-	const checkInterval = 30 * time.Second
+	initMetrics(cfg.Global.Buckets)
 
 	registry := prometheus.NewRegistry()
+	registry.MustRegister(allMetrics()...)
+	http.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
 
-	registry.MustRegister(pageSizeGauge)
+	moduleStore := newModuleStore(modules)
+	http.Handle("/probe", probeHandler(moduleStore))
 
-	http.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	originStore := newOriginStore(cfg.Beacon.AllowedOrigins)
+	pathStore := newPathStore(targetPaths(targets))
+	http.Handle("/beacon", beaconHandler(originStore, pathStore))
+
+	ctx := context.Background()
+	scheduler := NewScheduler(maxConcurrentScrapes)
+	scheduler.SetTargets(ctx, targets)
 
-	go monitorPages(urls, checkInterval)
+	go WatchConfig(ctx, *configFile, func(cfg *Config) {
+		targets, err := cfg.buildTargets()
+		if err != nil {
+			log.Printf("Reloaded config is invalid, keeping previous targets: %v\n", err)
+			return
+		}
+		modules, err := cfg.buildModules()
+		if err != nil {
+			log.Printf("Reloaded config has invalid probe modules, keeping previous modules: %v\n", err)
+		} else {
+			moduleStore.Set(modules)
+		}
+		originStore.Set(cfg.Beacon.AllowedOrigins)
+		pathStore.Set(targetPaths(targets))
+		scheduler.SetTargets(ctx, targets)
+	})
 
-	const port = 9222
-	fmt.Printf("Starting exporter on :%d\n", port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), nil))
+	fmt.Printf("Starting exporter on %s\n", *listenAddress)
+	log.Fatal(http.ListenAndServe(*listenAddress, nil))
 }
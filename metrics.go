@@ -0,0 +1,243 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsSet bundles every per-scrape collector so checkPageSize can record
+// into either the exporter's permanent registry (scheduled scrapes via the
+// Scheduler) or a fresh, request-scoped registry (the /probe endpoint)
+// without the two use cases sharing label values.
+type metricsSet struct {
+	pageSize      *prometheus.GaugeVec
+	status        *prometheus.GaugeVec
+	up            *prometheus.GaugeVec
+	lastScrape    *prometheus.GaugeVec
+	redirects     *prometheus.GaugeVec
+	fetchDur      *prometheus.HistogramVec
+	ttfbDur       *prometheus.HistogramVec
+	scrapeErrs    *prometheus.CounterVec
+	checkSuccess  *prometheus.GaugeVec
+	contentHash   *prometheus.GaugeVec
+	wireBytes     *prometheus.GaugeVec
+	decodedBytes  *prometheus.GaugeVec
+	bodyTruncated *prometheus.CounterVec
+
+	// lastHash tracks the most recently recorded content hash per URL, scoped
+	// to this metricsSet, so recordContentHash can retire the stale series
+	// instead of leaking one website_content_hash series per distinct body a
+	// target has ever served.
+	lastHashMu sync.Mutex
+	lastHash   map[string]string
+}
+
+// newMetricsSet builds a metricsSet whose histograms use buckets, or
+// prometheus.DefBuckets if buckets is empty.
+func newMetricsSet(buckets []float64) *metricsSet {
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+	return &metricsSet{
+		lastHash: map[string]string{},
+		pageSize: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "website_page_size_bytes",
+				Help: "Decoded size of the website page in bytes",
+			},
+			[]string{"url", "content_type"},
+		),
+		status: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "website_http_status_code",
+				Help: "HTTP status code returned by the last scrape",
+			},
+			[]string{"url"},
+		),
+		up: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "website_up",
+				Help: "Whether the last scrape of the URL succeeded (1) or failed (0)",
+			},
+			[]string{"url"},
+		),
+		lastScrape: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "website_last_scrape_timestamp_seconds",
+				Help: "Unix timestamp of the last scrape attempt",
+			},
+			[]string{"url"},
+		),
+		redirects: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "website_redirects",
+				Help: "Number of redirects followed during the last scrape",
+			},
+			[]string{"url"},
+		),
+		fetchDur: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "website_fetch_duration_seconds",
+				Help:    "Time to complete the full HTTP fetch, from request start to body read",
+				Buckets: buckets,
+			},
+			[]string{"url"},
+		),
+		ttfbDur: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "website_ttfb_seconds",
+				Help:    "Time to first byte of the response",
+				Buckets: buckets,
+			},
+			[]string{"url"},
+		),
+		scrapeErrs: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "website_scrape_errors_total",
+				Help: "Total scrape errors, classified by reason (dns, tls, timeout, non_2xx, other)",
+			},
+			[]string{"url", "reason"},
+		),
+		checkSuccess: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "website_check_success",
+				Help: "Whether a named content check passed (1) or failed (0) on the last scrape",
+			},
+			[]string{"url", "check"},
+		),
+		contentHash: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "website_content_hash",
+				Help: "Info-style metric: value is always 1, and the hash label carries the SHA-256 of the last fetched body",
+			},
+			[]string{"url", "hash"},
+		),
+		wireBytes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "website_wire_bytes",
+				Help: "Bytes of the response body read off the wire, before decompression; reading stops once max_body_bytes of decoded content is exceeded",
+			},
+			[]string{"url"},
+		),
+		decodedBytes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "website_decoded_bytes",
+				Help: "Size of the response body after decompression, capped at max_body_bytes",
+			},
+			[]string{"url"},
+		),
+		bodyTruncated: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "website_body_truncated_total",
+				Help: "Total scrapes where the decoded body exceeded max_body_bytes and was truncated",
+			},
+			[]string{"url"},
+		),
+	}
+}
+
+// collectors returns every collector in the set, for bulk registration.
+func (m *metricsSet) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.pageSize, m.status, m.up, m.lastScrape, m.redirects,
+		m.fetchDur, m.ttfbDur, m.scrapeErrs, m.checkSuccess, m.contentHash,
+		m.wireBytes, m.decodedBytes, m.bodyTruncated,
+	}
+}
+
+// deleteURL removes every series for url across the set's per-URL
+// collectors, regardless of their other label values (content_type, check,
+// hash, reason, ...), and forgets its tracked content hash. Used to retire a
+// target's metrics entirely rather than leaving them frozen at their last
+// value once it stops being scraped.
+func (m *metricsSet) deleteURL(url string) {
+	labels := prometheus.Labels{"url": url}
+	m.pageSize.DeletePartialMatch(labels)
+	m.status.DeletePartialMatch(labels)
+	m.up.DeletePartialMatch(labels)
+	m.lastScrape.DeletePartialMatch(labels)
+	m.redirects.DeletePartialMatch(labels)
+	m.fetchDur.DeletePartialMatch(labels)
+	m.ttfbDur.DeletePartialMatch(labels)
+	m.scrapeErrs.DeletePartialMatch(labels)
+	m.checkSuccess.DeletePartialMatch(labels)
+	m.contentHash.DeletePartialMatch(labels)
+	m.wireBytes.DeletePartialMatch(labels)
+	m.decodedBytes.DeletePartialMatch(labels)
+	m.bodyTruncated.DeletePartialMatch(labels)
+
+	m.lastHashMu.Lock()
+	delete(m.lastHash, url)
+	m.lastHashMu.Unlock()
+}
+
+// defaultMetrics is the permanent metric set behind the exporter's main
+// /metrics endpoint, fed by the Scheduler's scheduled scrapes. It, and the
+// package-level collectors below, are constructed by initMetrics once the
+// configured bucket boundaries are known, rather than at package init.
+var (
+	defaultMetrics    *metricsSet
+	scrapeDuration    *prometheus.HistogramVec
+	scrapesSkipped    *prometheus.CounterVec
+	clientReadSeconds *prometheus.HistogramVec
+
+	probeBuckets []float64
+)
+
+// initMetrics constructs defaultMetrics and the scheduler/beacon-only
+// collectors, using buckets for every histogram (falling back to
+// prometheus.DefBuckets when empty, the same rule newMetricsSet applies).
+// Must run once, before main registers any handler or starts the
+// Scheduler. probeHandler also uses buckets, via newMetricsSet(probeBuckets),
+// so an ad-hoc /probe histogram matches the configured resolution.
+func initMetrics(buckets []float64) {
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+	probeBuckets = buckets
+
+	defaultMetrics = newMetricsSet(buckets)
+
+	scrapeDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "website_scrape_duration_seconds",
+			Help:    "Time to complete a full scheduled scrape, including any retries",
+			Buckets: buckets,
+		},
+		[]string{"url"},
+	)
+
+	scrapesSkipped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "website_scrapes_skipped_total",
+			Help: "Total scheduled scrapes skipped instead of run, by reason",
+		},
+		[]string{"url", "reason"},
+	)
+
+	clientReadSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "website_client_read_seconds",
+			Help:    "Client-reported dwell time on a page, as submitted to /beacon",
+			Buckets: buckets,
+		},
+		[]string{"path"},
+	)
+}
+
+// allMetrics returns every collector the main /metrics endpoint registers.
+func allMetrics() []prometheus.Collector {
+	collectors := defaultMetrics.collectors()
+	return append(collectors, scrapeDuration, scrapesSkipped, clientReadSeconds)
+}
+
+// forgetTarget retires every series belonging to url across the permanent
+// metric set and the scheduler-only collectors, and forgets its last-seen
+// content hash. Called when SetTargets drops a target on config reload, so
+// a removed target doesn't linger in /metrics output at its last value.
+func forgetTarget(url string) {
+	defaultMetrics.deleteURL(url)
+	scrapeDuration.DeletePartialMatch(prometheus.Labels{"url": url})
+	scrapesSkipped.DeletePartialMatch(prometheus.Labels{"url": url})
+}
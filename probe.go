@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// defaultModuleName is used when a /probe request omits ?module=, mirroring
+// blackbox_exporter's convention for its built-in "do a plain GET" module.
+const defaultModuleName = "http_2xx"
+
+// probeModule is a named request template (a "module" in blackbox_exporter's
+// vocabulary) applied to an ad-hoc /probe target.
+type probeModule struct {
+	request requestConfig
+	checks  []checkSpec
+	timeout time.Duration
+}
+
+// defaultProbeModule is used when no module named defaultModuleName is
+// configured: a plain GET with no checks.
+func defaultProbeModule() probeModule {
+	return probeModule{
+		request: requestConfig{
+			Method:          http.MethodGet,
+			FollowRedirects: true,
+			Transport:       http.DefaultTransport.(*http.Transport).Clone(),
+		},
+		timeout: 10 * time.Second,
+	}
+}
+
+// moduleStore holds the current set of named probe modules, swappable on
+// config reload the same way the Scheduler's target set is.
+type moduleStore struct {
+	mu      sync.RWMutex
+	modules map[string]probeModule
+}
+
+func newModuleStore(modules map[string]probeModule) *moduleStore {
+	return &moduleStore{modules: modules}
+}
+
+func (s *moduleStore) Set(modules map[string]probeModule) {
+	s.mu.Lock()
+	s.modules = modules
+	s.mu.Unlock()
+}
+
+func (s *moduleStore) Get(name string) (probeModule, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m, ok := s.modules[name]
+	return m, ok
+}
+
+// probeHandler implements a blackbox_exporter-style /probe endpoint: a
+// one-shot fetch of ?target= using the named ?module=, scored onto a fresh
+// prometheus.Registry per request so ad-hoc targets never accumulate as
+// permanent label values on the exporter's main /metrics output.
+func probeHandler(store *moduleStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		targetURL := r.URL.Query().Get("target")
+		if targetURL == "" {
+			http.Error(w, "target parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		moduleName := r.URL.Query().Get("module")
+		if moduleName == "" {
+			moduleName = defaultModuleName
+		}
+
+		module, ok := store.Get(moduleName)
+		if !ok {
+			if moduleName != defaultModuleName {
+				http.Error(w, fmt.Sprintf("unknown module %q", moduleName), http.StatusBadRequest)
+				return
+			}
+			module = defaultProbeModule()
+		}
+
+		registry := prometheus.NewRegistry()
+		m := newMetricsSet(probeBuckets)
+		registry.MustRegister(m.collectors()...)
+
+		probeSuccess := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_success",
+			Help: "Whether the probe succeeded (1) or failed (0)",
+		})
+		registry.MustRegister(probeSuccess)
+
+		t := target{
+			url:     targetURL,
+			checks:  module.checks,
+			request: module.request,
+		}
+		client := &http.Client{Timeout: module.timeout, Transport: module.request.Transport}
+
+		if checkPageSize(t, client, m) {
+			probeSuccess.Set(1)
+		}
+
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}
+}
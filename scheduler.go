@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// schedule describes how often and how aggressively a target is probed.
+type schedule struct {
+	Interval   time.Duration
+	Timeout    time.Duration
+	Jitter     time.Duration
+	MaxRetries int
+	Backoff    time.Duration
+	Cron       string
+}
+
+func defaultSchedule() schedule {
+	return schedule{
+		Interval:   30 * time.Second,
+		Timeout:    10 * time.Second,
+		Jitter:     5 * time.Second,
+		MaxRetries: 0,
+		Backoff:    time.Second,
+	}
+}
+
+// Scheduler fires checkPageSize against each target on its own schedule,
+// through a bounded pool of workers shared across all targets. Its target
+// set can be swapped at any time via SetTargets, which is how config
+// hot-reload works.
+type Scheduler struct {
+	sem chan struct{}
+
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewScheduler builds a Scheduler that never runs more than concurrency
+// scrapes at once, regardless of how many targets are due at the same time.
+func NewScheduler(concurrency int) *Scheduler {
+	return &Scheduler{
+		sem:     make(chan struct{}, concurrency),
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+// SetTargets replaces the running target set. Targets are restarted on
+// their own schedule; an in-flight scrape (already past its scheduling
+// select) runs to completion with its own client and timeout, so a reload
+// never drops one mid-flight. ctx bounds the lifetime of every target's
+// scheduling loop. Any URL present in the old set but absent from targets
+// has its metrics retired via forgetTarget, so a target dropped by a
+// reload stops reporting instead of reporting its last value forever.
+func (s *Scheduler) SetTargets(ctx context.Context, targets []target) {
+	s.mu.Lock()
+	old := s.cancels
+	s.cancels = make(map[string]context.CancelFunc, len(targets))
+
+	keep := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		keep[t.url] = true
+		tctx, cancel := context.WithCancel(ctx)
+		s.cancels[t.url] = cancel
+		s.wg.Add(1)
+		go func(t target) {
+			defer s.wg.Done()
+			s.runTarget(tctx, t)
+		}(t)
+	}
+	s.mu.Unlock()
+
+	for url, cancel := range old {
+		cancel()
+		if !keep[url] {
+			forgetTarget(url)
+		}
+	}
+}
+
+// Wait blocks until every target's scheduling loop has returned, which
+// happens once their context is canceled.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) runTarget(ctx context.Context, t target) {
+	sched := t.schedule
+
+	// Stagger initial fires deterministically from a hash of the target's
+	// URL, the same trick Prometheus uses for scrape jitter, so restarting
+	// the exporter doesn't re-synchronize every target onto the same tick.
+	select {
+	case <-time.After(targetJitter(t.url, sched.Jitter)):
+	case <-ctx.Done():
+		return
+	}
+
+	var cronSchedule cron.Schedule
+	if sched.Cron != "" {
+		parsed, err := cron.ParseStandard(sched.Cron)
+		if err != nil {
+			log.Printf("Invalid cron expression %q for %s, falling back to interval: %v\n", sched.Cron, t.url, err)
+		} else {
+			cronSchedule = parsed
+		}
+	}
+
+	for {
+		s.fire(ctx, t)
+
+		wait := sched.Interval
+		if cronSchedule != nil {
+			wait = time.Until(cronSchedule.Next(time.Now()))
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// fire runs one scrape of t if a worker slot is free, or records an overrun
+// skip if the pool is saturated. It deliberately does not block waiting for
+// a slot: a blocked scrape would just delay the next tick further.
+func (s *Scheduler) fire(ctx context.Context, t target) {
+	select {
+	case s.sem <- struct{}{}:
+	default:
+		scrapesSkipped.WithLabelValues(t.url, "overrun").Inc()
+		return
+	}
+	defer func() { <-s.sem }()
+
+	start := time.Now()
+	runWithRetries(ctx, t)
+	scrapeDuration.WithLabelValues(t.url).Observe(time.Since(start).Seconds())
+}
+
+func runWithRetries(ctx context.Context, t target) {
+	client := &http.Client{Timeout: t.schedule.Timeout, Transport: t.request.Transport}
+	attempts := t.schedule.MaxRetries + 1
+	backoff := t.schedule.Backoff
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			backoff *= 2
+		}
+
+		if ok := checkPageSize(t, client, defaultMetrics); ok || attempt == attempts-1 {
+			return
+		}
+	}
+}
+
+// targetJitter derives a deterministic delay in [0, max) from the target's
+// URL so repeated restarts stagger the same way instead of randomly. The
+// hash is scaled as a fraction of max rather than reduced by it directly:
+// h.Sum32() only spans about 4.295s taken as a nanosecond count on its own,
+// so any max above that would never be used past its first ~4.3s.
+func targetJitter(url string, max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(url))
+	frac := float64(h.Sum32()) / (float64(math.MaxUint32) + 1)
+	return time.Duration(frac * float64(max))
+}